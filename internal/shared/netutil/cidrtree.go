@@ -0,0 +1,91 @@
+package netutil
+
+import "net"
+
+// ruleVerdict is the terminal decision stored at a matching node of a cidrTree.
+type ruleVerdict struct {
+	allow    bool
+	priority int // insertion order, kept for diagnostics/future tie-breaking
+}
+
+// cidrNode is one node of a binary radix trie keyed on IP address bits.
+// children[0] is the subtree for a 0 bit, children[1] for a 1 bit.
+type cidrNode struct {
+	children [2]*cidrNode
+	verdict  *ruleVerdict
+}
+
+// cidrTree is a longest-prefix-match radix trie over CIDR ranges. IPv4 and
+// IPv6 addresses are stored in separate trees since they have different bit
+// widths, so lookups never walk more bits than the address actually has.
+type cidrTree struct {
+	v4 *cidrNode
+	v6 *cidrNode
+	n  int // number of rules inserted so far, used to assign insertion priority
+}
+
+func newCIDRTree() *cidrTree {
+	return &cidrTree{v4: &cidrNode{}, v6: &cidrNode{}}
+}
+
+// insert adds ipNet to the tree with the given allow decision. Bits are
+// walked from the most significant bit. If a rule already terminates at the
+// same node (i.e. the same prefix was inserted more than once), deny takes
+// precedence over allow, matching IPAccessChecker's historical "deny wins"
+// behavior for equally-specific rules.
+func (t *cidrTree) insert(ipNet *net.IPNet, allow bool) {
+	root, addr := t.rootFor(ipNet.IP)
+	ones, _ := ipNet.Mask.Size()
+
+	node := root
+	for i := 0; i < ones; i++ {
+		bit := ipBit(addr, i)
+		if node.children[bit] == nil {
+			node.children[bit] = &cidrNode{}
+		}
+		node = node.children[bit]
+	}
+
+	t.n++
+	if node.verdict == nil || (!allow && node.verdict.allow) {
+		node.verdict = &ruleVerdict{allow: allow, priority: t.n}
+	}
+}
+
+// lookup walks the bits of ip, remembering the deepest terminal node
+// visited, and returns its verdict (or nil if no rule matched at all).
+func (t *cidrTree) lookup(ip net.IP) *ruleVerdict {
+	root, addr, bits := t.rootAndBitsFor(ip)
+
+	node := root
+	best := node.verdict
+	for i := 0; i < bits && node != nil; i++ {
+		node = node.children[ipBit(addr, i)]
+		if node != nil && node.verdict != nil {
+			best = node.verdict
+		}
+	}
+	return best
+}
+
+// rootFor returns the tree (v4 or v6) and the address bytes to walk for ip.
+func (t *cidrTree) rootFor(ip net.IP) (*cidrNode, net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return t.v4, ip4
+	}
+	return t.v6, ip.To16()
+}
+
+func (t *cidrTree) rootAndBitsFor(ip net.IP) (*cidrNode, net.IP, int) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return t.v4, ip4, 32
+	}
+	return t.v6, ip.To16(), 128
+}
+
+// ipBit returns bit i of addr, counting from the most significant bit (0).
+func ipBit(addr net.IP, i int) int {
+	byteIndex := i / 8
+	shift := uint(7 - i%8)
+	return int((addr[byteIndex] >> shift) & 1)
+}
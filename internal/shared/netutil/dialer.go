@@ -0,0 +1,77 @@
+package netutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DialContext returns a dial function suitable for http.Transport.DialContext
+// (or any net.Dialer-consuming code) that enforces this checker's access
+// rules on the resolved destination, not just the literal host string. It
+// resolves addr's host via the default resolver, rechecks IsAllowedAddr
+// against every resolved IP and the destination port, and rejects the whole
+// dial if any of them is denied. This defeats DNS rebinding attacks where a
+// hostname passes an initial check but resolves to a disallowed address at
+// connect time, and it also enforces port-scoped rules (checkers built with
+// NewIPAccessCheckerWithRules) against the real destination port rather than
+// silently falling through to defaultAllow.
+func (c *IPAccessChecker) DialContext(base *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if base == nil {
+		base = &net.Dialer{}
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("netutil: invalid port %q in address %q: %w", portStr, addr, err)
+		}
+
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("netutil: no addresses found for host %q", host)
+		}
+
+		for _, a := range addrs {
+			if !c.IsAllowedAddr(a.IP.String(), port) {
+				return nil, fmt.Errorf("netutil: address %s:%d for host %q is not allowed", a.IP, port, host)
+			}
+		}
+
+		// Dial the first resolved address directly so we connect to the
+		// exact IP we just checked, rather than letting the dialer
+		// re-resolve the hostname and possibly pick a different (unchecked)
+		// address.
+		return base.DialContext(ctx, network, net.JoinHostPort(addrs[0].IP.String(), portStr))
+	}
+}
+
+// HTTPTransport returns an *http.Transport preconfigured with checker's
+// SSRF-safe DialContext and sane timeouts, ready to use as the Transport of
+// an http.Client for outbound requests that must not be allowed to reach
+// disallowed or internal addresses.
+func HTTPTransport(checker *IPAccessChecker) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		DialContext:           checker.DialContext(dialer),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	}
+}
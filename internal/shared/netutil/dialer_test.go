@@ -0,0 +1,39 @@
+package netutil
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// TestDialContext_EnforcesPortScopedDeny bypasses DNS by dialing a literal IP
+// address directly, so DialContext's port-scoped check can be exercised
+// without a real network lookup (127.0.0.1 resolves to itself via
+// LookupIPAddr regardless).
+func TestDialContext_EnforcesPortScopedDeny(t *testing.T) {
+	checker, err := NewIPAccessCheckerWithRules(true, []Rule{
+		{CIDR: "127.0.0.0/8", Ports: []int{22}, Allow: false},
+	})
+	if err != nil {
+		t.Fatalf("NewIPAccessCheckerWithRules: %v", err)
+	}
+
+	dial := checker.DialContext(&net.Dialer{})
+
+	_, err = dial(context.Background(), "tcp", "127.0.0.1:22")
+	if err == nil {
+		t.Fatal("expected dial to 127.0.0.1:22 to be rejected by the port-scoped deny rule")
+	}
+	if !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("expected a not-allowed error, got: %v", err)
+	}
+
+	// Port 80 isn't covered by the deny rule, so defaultAllow (true) should
+	// let the dial proceed past the access check (it may still fail to
+	// actually connect, which is fine for this test).
+	_, err = dial(context.Background(), "tcp", "127.0.0.1:80")
+	if err != nil && strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("expected port 80 to pass the access check, got: %v", err)
+	}
+}
@@ -1,16 +1,39 @@
 package netutil
 
 import (
+	"fmt"
 	"net"
+	"sort"
 	"strings"
+	"sync/atomic"
 )
 
 // IPAccessChecker checks if an IP address is allowed based on whitelist/blacklist rules.
 type IPAccessChecker struct {
-	allowNets []*net.IPNet // Allowed CIDR ranges (whitelist)
-	denyNets  []*net.IPNet // Denied CIDR ranges (blacklist)
-	hasAllow  bool         // Whether whitelist is configured
-	hasDeny   bool         // Whether blacklist is configured
+	ruleSet atomic.Pointer[ipRuleSet] // Current allow/deny rule set, swappable at runtime
+
+	useRules     bool         // Whether port-scoped rules are configured
+	rules        []parsedRule // Port-scoped rules, in declaration order
+	defaultAllow bool         // Fallback decision when no rule matches
+
+	onReload atomic.Pointer[func(added, removed int, err error)] // StartAutoReload callback
+}
+
+// Rule describes a single port-scoped IP access rule.
+// CIDR is the network the rule applies to, Ports restricts the rule to the
+// given destination ports (empty means any port), and Allow is the
+// decision to apply when the rule matches.
+type Rule struct {
+	CIDR  string
+	Ports []int
+	Allow bool
+}
+
+// parsedRule is the validated, ready-to-evaluate form of a Rule.
+type parsedRule struct {
+	ipNet *net.IPNet
+	ports []int // sorted ascending; empty means any port
+	allow bool
 }
 
 // NewIPAccessChecker creates a new IP access checker from CIDR and IP lists.
@@ -18,71 +41,125 @@ type IPAccessChecker struct {
 // denyIPs: list of CIDR ranges or IP addresses to deny (e.g., "192.168.0.0/16", "1.2.3.4")
 func NewIPAccessChecker(allowCIDRs, denyIPs []string) *IPAccessChecker {
 	checker := &IPAccessChecker{}
+	checker.ruleSet.Store(buildRuleSet(allowCIDRs, denyIPs))
+	return checker
+}
 
-	// Parse allowed CIDRs
-	for _, cidr := range allowCIDRs {
-		cidr = strings.TrimSpace(cidr)
-		if cidr == "" {
-			continue
-		}
+// NewIPAccessCheckerWithRules creates an IP access checker evaluated against
+// an ordered list of port-scoped rules instead of flat allow/deny lists.
+// Rules are evaluated in declaration order: the first rule whose CIDR
+// contains the IP and whose port list matches (or is empty) decides the
+// result. If no rule matches, defaultAllow is returned.
+//
+// Each rule is validated at construction time: CIDR must parse and every
+// port must be in [1, 65535].
+func NewIPAccessCheckerWithRules(defaultAllow bool, rules []Rule) (*IPAccessChecker, error) {
+	checker := &IPAccessChecker{
+		useRules:     true,
+		defaultAllow: defaultAllow,
+	}
 
-		// If no "/" in the string, treat it as a single IP (/32 for IPv4, /128 for IPv6)
+	for i, rule := range rules {
+		cidr := strings.TrimSpace(rule.CIDR)
 		if !strings.Contains(cidr, "/") {
 			ip := net.ParseIP(cidr)
-			if ip != nil {
-				if ip.To4() != nil {
-					cidr = cidr + "/32"
-				} else {
-					cidr = cidr + "/128"
-				}
+			if ip == nil {
+				return nil, fmt.Errorf("netutil: rule %d: invalid CIDR %q", i, rule.CIDR)
+			}
+			if ip.To4() != nil {
+				cidr = cidr + "/32"
+			} else {
+				cidr = cidr + "/128"
 			}
 		}
 
 		_, ipNet, err := net.ParseCIDR(cidr)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("netutil: rule %d: invalid CIDR %q: %w", i, rule.CIDR, err)
 		}
-		checker.allowNets = append(checker.allowNets, ipNet)
-	}
-	checker.hasAllow = len(checker.allowNets) > 0
 
-	// Parse denied IPs/CIDRs
-	for _, ipStr := range denyIPs {
-		ipStr = strings.TrimSpace(ipStr)
-		if ipStr == "" {
-			continue
-		}
-
-		// If no "/" in the string, treat it as a single IP (/32 for IPv4, /128 for IPv6)
-		if !strings.Contains(ipStr, "/") {
-			ip := net.ParseIP(ipStr)
-			if ip != nil {
-				if ip.To4() != nil {
-					ipStr = ipStr + "/32"
-				} else {
-					ipStr = ipStr + "/128"
-				}
+		ports := make([]int, len(rule.Ports))
+		copy(ports, rule.Ports)
+		sort.Ints(ports)
+		for _, p := range ports {
+			if p < 1 || p > 65535 {
+				return nil, fmt.Errorf("netutil: rule %d: port %d out of range [1, 65535]", i, p)
 			}
 		}
 
-		_, ipNet, err := net.ParseCIDR(ipStr)
-		if err != nil {
-			continue
-		}
-		checker.denyNets = append(checker.denyNets, ipNet)
+		checker.rules = append(checker.rules, parsedRule{
+			ipNet: ipNet,
+			ports: ports,
+			allow: rule.Allow,
+		})
 	}
-	checker.hasDeny = len(checker.denyNets) > 0
 
-	return checker
+	return checker, nil
 }
 
 // IsAllowed checks if the given IP address is allowed.
-// Rules:
-// 1. If IP is in deny list, reject
-// 2. If whitelist is configured and IP is not in whitelist, reject
-// 3. Otherwise, allow
+//
+// BEHAVIOR CHANGE: prior versions of this checker treated the deny list as
+// always taking priority over the allow list, regardless of which CIDR was
+// more specific. As of the radix-tree rewrite, allow and deny CIDRs are
+// unified into a single longest-prefix-match tree: the most specific
+// matching CIDR wins outright, and only an exact tie in prefix length falls
+// back to "deny wins". Concretely, NewIPAccessChecker([]string{"10.0.0.0/16"},
+// []string{"10.0.0.0/8"}).IsAllowed("10.0.1.1") now returns true, where it
+// used to return false. Any existing rule set that relies on a broad deny
+// overriding a narrower allow carved out of it must be rewritten (e.g. by
+// deny-listing the narrower range explicitly) to keep its old behavior.
+//
+// Rules, for a checker built with NewIPAccessChecker:
+// 1. The most specific matching CIDR (allow or deny) decides the result.
+// 2. If no CIDR matches and a whitelist is configured, reject.
+// 3. Otherwise, allow.
+//
+// For a checker built with NewIPAccessCheckerWithRules, IsAllowed is
+// IsAllowedAddr at port 0: rules with a non-empty port list never match
+// (port 0 is never in them), the remaining rules are evaluated in
+// declaration order, and defaultAllow applies when none matches.
 func (c *IPAccessChecker) IsAllowed(ipStr string) bool {
-	if c == nil || (!c.hasAllow && !c.hasDeny) {
+	return c.IsAllowedAddr(ipStr, 0)
+}
+
+// IsAllowedAddr checks if the given IP address is allowed for the given
+// destination port. For a checker built with NewIPAccessCheckerWithRules,
+// rules are evaluated in declaration order: a rule matches when its CIDR
+// contains the IP and its port list is empty or contains port (via binary
+// search over the sorted port list); the first match decides the result,
+// falling through to later rules and finally to defaultAllow otherwise.
+//
+// For a checker built with NewIPAccessChecker, port is ignored and behavior
+// is identical to IsAllowed. The rule set is read with a single
+// atomic load, so a concurrent StartAutoReload swap never blocks or
+// fragments this check.
+func (c *IPAccessChecker) IsAllowedAddr(ipStr string, port int) bool {
+	if c == nil {
+		return true // No rules configured, allow all
+	}
+
+	if c.useRules {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return false // Invalid IP, reject
+		}
+
+		for _, rule := range c.rules {
+			if !rule.ipNet.Contains(ip) {
+				continue
+			}
+			if len(rule.ports) == 0 || portInSortedList(rule.ports, port) {
+				return rule.allow
+			}
+			// Port doesn't match this rule; fall through to later rules.
+		}
+
+		return c.defaultAllow
+	}
+
+	rs := c.ruleSet.Load()
+	if rs == nil || (!rs.hasAllow && !rs.hasDeny) {
 		return true // No rules configured, allow all
 	}
 
@@ -91,31 +168,35 @@ func (c *IPAccessChecker) IsAllowed(ipStr string) bool {
 		return false // Invalid IP, reject
 	}
 
-	// Check deny list first (blacklist takes priority)
-	if c.hasDeny {
-		for _, denyNet := range c.denyNets {
-			if denyNet.Contains(ip) {
-				return false
-			}
-		}
+	// Longest-prefix match across allow and deny CIDRs: the most specific
+	// rule wins, with deny winning ties at equal specificity.
+	if v := rs.tree.lookup(ip); v != nil {
+		return v.allow
 	}
 
-	// Check allow list (whitelist)
-	if c.hasAllow {
-		for _, allowNet := range c.allowNets {
-			if allowNet.Contains(ip) {
-				return true
-			}
-		}
-		return false // Whitelist configured but IP not in it
+	if rs.hasAllow {
+		return false // Whitelist configured but IP matched no rule
 	}
 
 	return true // No whitelist, and not in blacklist
 }
 
+// portInSortedList reports whether port appears in the ascending-sorted ports slice.
+func portInSortedList(ports []int, port int) bool {
+	i := sort.SearchInts(ports, port)
+	return i < len(ports) && ports[i] == port
+}
+
 // HasRules returns true if any access control rules are configured.
 func (c *IPAccessChecker) HasRules() bool {
-	return c != nil && (c.hasAllow || c.hasDeny)
+	if c == nil {
+		return false
+	}
+	if len(c.rules) > 0 {
+		return true
+	}
+	rs := c.ruleSet.Load()
+	return rs != nil && (rs.hasAllow || rs.hasDeny)
 }
 
 // ExtractIP extracts the IP address from a remote address string (e.g., "192.168.1.1:12345").
@@ -0,0 +1,81 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+// linearChecker mirrors the pre-tree IPAccessChecker.IsAllowed algorithm: a
+// plain scan over the deny and allow net slices. It exists only to give the
+// tree-based implementation something to benchmark against.
+type linearChecker struct {
+	allowNets []*net.IPNet
+	denyNets  []*net.IPNet
+}
+
+func (c *linearChecker) isAllowed(ip net.IP) bool {
+	for _, denyNet := range c.denyNets {
+		if denyNet.Contains(ip) {
+			return false
+		}
+	}
+	if len(c.allowNets) > 0 {
+		for _, allowNet := range c.allowNets {
+			if allowNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// benchCIDRs generates n /24 CIDRs spread across the 10.0.0.0/8 space,
+// alternating allow/deny so both structures have real work to do. The space
+// holds only 65,536 /24s, so for larger n the sequence wraps around and
+// entries repeat.
+func benchCIDRs(n int) (allow, deny []string) {
+	for i := 0; i < n; i++ {
+		cidr := fmt.Sprintf("10.%d.%d.0/24", (i/256)%256, i%256)
+		if i%2 == 0 {
+			allow = append(allow, cidr)
+		} else {
+			deny = append(deny, cidr)
+		}
+	}
+	return allow, deny
+}
+
+func BenchmarkIsAllowed(b *testing.B) {
+	for _, n := range []int{10, 1000, 100000} {
+		allow, deny := benchCIDRs(n)
+		probe := net.ParseIP("10.1.2.3")
+
+		b.Run(fmt.Sprintf("linear/%d", n), func(b *testing.B) {
+			lc := &linearChecker{}
+			for _, cidr := range allow {
+				_, ipNet, _ := net.ParseCIDR(cidr)
+				lc.allowNets = append(lc.allowNets, ipNet)
+			}
+			for _, cidr := range deny {
+				_, ipNet, _ := net.ParseCIDR(cidr)
+				lc.denyNets = append(lc.denyNets, ipNet)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lc.isAllowed(probe)
+			}
+		})
+
+		b.Run(fmt.Sprintf("tree/%d", n), func(b *testing.B) {
+			checker := NewIPAccessChecker(allow, deny)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				checker.IsAllowed("10.1.2.3")
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package netutil
+
+import "testing"
+
+func TestIsAllowedAddr_PortScopedRules(t *testing.T) {
+	checker, err := NewIPAccessCheckerWithRules(true, []Rule{
+		{CIDR: "10.0.0.0/8", Ports: []int{22}, Allow: false},
+		{CIDR: "10.0.0.0/8", Allow: true},
+	})
+	if err != nil {
+		t.Fatalf("NewIPAccessCheckerWithRules: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		ip     string
+		port   int
+		expect bool
+	}{
+		{"denied port matches first rule", "10.1.2.3", 22, false},
+		{"other port falls through to the any-port allow rule", "10.1.2.3", 80, true},
+		{"ip outside both rules uses defaultAllow", "192.168.1.1", 22, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checker.IsAllowedAddr(tt.ip, tt.port); got != tt.expect {
+				t.Errorf("IsAllowedAddr(%q, %d) = %v, want %v", tt.ip, tt.port, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestIsAllowed_ShimTreatsPortScopedRulesAsNonMatching(t *testing.T) {
+	checker, err := NewIPAccessCheckerWithRules(true, []Rule{
+		{CIDR: "10.0.0.0/8", Ports: []int{22}, Allow: false},
+	})
+	if err != nil {
+		t.Fatalf("NewIPAccessCheckerWithRules: %v", err)
+	}
+
+	// IsAllowed evaluates at port 0, which never satisfies a non-empty
+	// port list, so the deny rule never matches and defaultAllow applies.
+	if !checker.IsAllowed("10.1.2.3") {
+		t.Fatal("expected IsAllowed to fall through a port-scoped rule to defaultAllow")
+	}
+}
+
+func TestNewIPAccessCheckerWithRules_Validation(t *testing.T) {
+	if _, err := NewIPAccessCheckerWithRules(true, []Rule{{CIDR: "not-a-cidr"}}); err == nil {
+		t.Fatal("expected an error for an invalid CIDR")
+	}
+	if _, err := NewIPAccessCheckerWithRules(true, []Rule{{CIDR: "10.0.0.0/8", Ports: []int{0}}}); err == nil {
+		t.Fatal("expected an error for a port below 1")
+	}
+	if _, err := NewIPAccessCheckerWithRules(true, []Rule{{CIDR: "10.0.0.0/8", Ports: []int{65536}}}); err == nil {
+		t.Fatal("expected an error for a port above 65535")
+	}
+}
@@ -0,0 +1,51 @@
+package netutil
+
+import "testing"
+
+func TestIsAllowed_LongestPrefixWins(t *testing.T) {
+	tests := []struct {
+		name   string
+		allow  []string
+		deny   []string
+		ip     string
+		expect bool
+	}{
+		{
+			name:   "narrower deny inside broader allow rejects",
+			allow:  []string{"10.0.0.0/8"},
+			deny:   []string{"10.1.2.0/24"},
+			ip:     "10.1.2.5",
+			expect: false,
+		},
+		{
+			name:   "narrower allow inside broader deny allows",
+			allow:  []string{"10.0.0.0/16"},
+			deny:   []string{"10.0.0.0/8"},
+			ip:     "10.0.1.1",
+			expect: true,
+		},
+		{
+			name:   "equal prefix length ties to deny",
+			allow:  []string{"192.168.1.0/24"},
+			deny:   []string{"192.168.1.0/24"},
+			ip:     "192.168.1.1",
+			expect: false,
+		},
+		{
+			name:   "no match with whitelist configured rejects",
+			allow:  []string{"10.0.0.0/16"},
+			deny:   []string{"10.0.0.0/8"},
+			ip:     "172.16.0.1",
+			expect: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewIPAccessChecker(tt.allow, tt.deny)
+			if got := checker.IsAllowed(tt.ip); got != tt.expect {
+				t.Errorf("IsAllowed(%q) = %v, want %v", tt.ip, got, tt.expect)
+			}
+		})
+	}
+}
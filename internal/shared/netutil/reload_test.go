@@ -0,0 +1,175 @@
+package netutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type staticRuleSource struct {
+	allow, deny []string
+}
+
+func (s *staticRuleSource) Load(ctx context.Context) (allowCIDRs, denyCIDRs []string, err error) {
+	return s.allow, s.deny, nil
+}
+
+func TestStartAutoReload_RejectsRuleBasedChecker(t *testing.T) {
+	checker, err := NewIPAccessCheckerWithRules(true, nil)
+	if err != nil {
+		t.Fatalf("NewIPAccessCheckerWithRules: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := checker.StartAutoReload(ctx, &staticRuleSource{}, time.Hour); err == nil {
+		t.Fatal("expected StartAutoReload to reject a checker built with NewIPAccessCheckerWithRules")
+	}
+}
+
+func TestReloadFrom_SkipsRebuildWhenUnchanged(t *testing.T) {
+	checker := NewIPAccessChecker([]string{"10.0.0.0/8"}, nil)
+	src := &staticRuleSource{allow: []string{"10.0.0.0/8"}}
+
+	before := checker.ruleSet.Load()
+	checker.reloadFrom(context.Background(), src)
+	after := checker.ruleSet.Load()
+
+	if before != after {
+		t.Fatal("expected reloadFrom to skip rebuilding and swapping an unchanged rule set")
+	}
+}
+
+func TestReloadFrom_RebuildsWhenChanged(t *testing.T) {
+	checker := NewIPAccessChecker([]string{"10.0.0.0/8"}, nil)
+	src := &staticRuleSource{allow: []string{"10.0.0.0/8", "192.168.0.0/16"}}
+
+	before := checker.ruleSet.Load()
+	checker.reloadFrom(context.Background(), src)
+	after := checker.ruleSet.Load()
+
+	if before == after {
+		t.Fatal("expected reloadFrom to rebuild and swap when the rule set changed")
+	}
+	if !checker.IsAllowed("192.168.1.1") {
+		t.Fatal("expected newly-added allow CIDR to take effect after reload")
+	}
+}
+
+func TestPollInterval_PrefersSourceOverFallback(t *testing.T) {
+	httpSrc := &HTTPRuleSource{URL: "http://example.invalid/rules", Interval: 30 * time.Second}
+	if got := pollInterval(httpSrc, 5*time.Minute); got != 30*time.Second {
+		t.Fatalf("pollInterval() = %v, want HTTPRuleSource.Interval (30s)", got)
+	}
+
+	httpSrcNoInterval := &HTTPRuleSource{URL: "http://example.invalid/rules"}
+	if got := pollInterval(httpSrcNoInterval, 5*time.Minute); got != 5*time.Minute {
+		t.Fatalf("pollInterval() = %v, want the fallback (5m) when Interval is unset", got)
+	}
+
+	fileSrc := &FileRuleSource{Path: "/dev/null"}
+	if got := pollInterval(fileSrc, 5*time.Minute); got != 5*time.Minute {
+		t.Fatalf("pollInterval() = %v, want the fallback (5m) for a source with no PollInterval", got)
+	}
+}
+
+func TestHTTPRuleSource_Load(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == "v1" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", "v1")
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2026 07:28:00 GMT")
+		_, _ = w.Write([]byte("allow 10.0.0.0/8\ndeny 192.168.0.0/16\n"))
+	}))
+	defer srv.Close()
+
+	src := &HTTPRuleSource{URL: srv.URL, ETag: true}
+
+	allow, deny, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(allow) != 1 || allow[0] != "10.0.0.0/8" || len(deny) != 1 || deny[0] != "192.168.0.0/16" {
+		t.Fatalf("unexpected first load: allow=%v deny=%v", allow, deny)
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 request, got %d", requests)
+	}
+
+	// Second Load must send If-None-Match: v1 and, per the handler above,
+	// get back a 304 — confirming the cached lists are reused rather than
+	// re-parsed.
+	allow, deny, err = src.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(allow) != 1 || allow[0] != "10.0.0.0/8" || len(deny) != 1 || deny[0] != "192.168.0.0/16" {
+		t.Fatalf("expected cached result on 304, got allow=%v deny=%v", allow, deny)
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 requests total, got %d", requests)
+	}
+}
+
+func TestFileRuleSource_SkipsReparseUntilMTimeChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.txt")
+
+	if err := os.WriteFile(path, []byte("allow 10.0.0.0/8\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	mtime := info.ModTime()
+
+	src := &FileRuleSource{Path: path}
+	allow, deny, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(allow) != 1 || allow[0] != "10.0.0.0/8" || len(deny) != 0 {
+		t.Fatalf("unexpected first load: allow=%v deny=%v", allow, deny)
+	}
+
+	// Change the file's content but pin the mtime back to its original
+	// value: Load should keep serving the cached result.
+	if err := os.WriteFile(path, []byte("deny 192.168.0.0/16\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	allow, deny, err = src.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(allow) != 1 || allow[0] != "10.0.0.0/8" || len(deny) != 0 {
+		t.Fatalf("expected cached result with unchanged mtime, got allow=%v deny=%v", allow, deny)
+	}
+
+	// Advance the mtime: Load must now pick up the new content.
+	newMTime := mtime.Add(time.Second)
+	if err := os.Chtimes(path, newMTime, newMTime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	allow, deny, err = src.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(deny) != 1 || deny[0] != "192.168.0.0/16" || len(allow) != 0 {
+		t.Fatalf("expected reload after mtime change, got allow=%v deny=%v", allow, deny)
+	}
+}
@@ -0,0 +1,121 @@
+package netutil
+
+import (
+	"net"
+	"strings"
+)
+
+// TrustedProxies holds a set of CIDR ranges that are trusted to supply
+// accurate client-IP information via proxy headers (X-Forwarded-For,
+// X-Real-IP). It is used to resolve the true client IP behind L7 proxies
+// without letting an untrusted client spoof those headers.
+type TrustedProxies struct {
+	nets []*net.IPNet
+}
+
+// NewTrustedProxies builds a TrustedProxies set from a list of CIDR ranges
+// or bare IP addresses (treated as /32 or /128). Invalid entries are
+// skipped.
+func NewTrustedProxies(cidrs []string) *TrustedProxies {
+	tp := &TrustedProxies{}
+
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip != nil {
+				if ip.To4() != nil {
+					cidr = cidr + "/32"
+				} else {
+					cidr = cidr + "/128"
+				}
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		tp.nets = append(tp.nets, ipNet)
+	}
+
+	return tp
+}
+
+// contains reports whether ip falls within any trusted proxy net.
+func (t *TrustedProxies) contains(ip net.IP) bool {
+	if t == nil {
+		return false
+	}
+	for _, n := range t.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP resolves the true client IP for a request received from
+// remoteAddr, given its X-Forwarded-For and X-Real-IP header values.
+//
+// If the connection peer (remoteAddr) is not itself a trusted proxy, its
+// address is returned unchanged and the headers are ignored, since an
+// untrusted peer can set them to anything. Otherwise, X-Forwarded-For is
+// parsed right-to-left, skipping entries that are themselves trusted
+// proxies, and the first untrusted address found is returned. If every
+// entry is trusted (or the header is empty), X-Real-IP is used instead,
+// falling back to the peer address if that is also unusable.
+func (t *TrustedProxies) ClientIP(remoteAddr string, xff, xRealIP string) string {
+	peer := ExtractIP(remoteAddr)
+
+	peerIP := net.ParseIP(peer)
+	if peerIP == nil || !t.contains(peerIP) {
+		return peer
+	}
+
+	for _, part := range reverseSplit(xff, ",") {
+		candidate := strings.TrimSpace(part)
+		if candidate == "" {
+			continue
+		}
+		ip := net.ParseIP(candidate)
+		if ip == nil {
+			continue
+		}
+		if t.contains(ip) {
+			continue
+		}
+		return candidate
+	}
+
+	realIP := strings.TrimSpace(xRealIP)
+	if realIP != "" && net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return peer
+}
+
+// reverseSplit splits s on sep and returns the parts in reverse order.
+func reverseSplit(s, sep string) []string {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, sep)
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return parts
+}
+
+// IsAllowedRequest composes client-IP resolution with access control: it
+// determines the true client IP behind any trusted proxies and checks it
+// against the checker's rules.
+func (c *IPAccessChecker) IsAllowedRequest(remoteAddr, xff, xRealIP string, trusted *TrustedProxies) bool {
+	clientIP := trusted.ClientIP(remoteAddr, xff, xRealIP)
+	return c.IsAllowed(clientIP)
+}
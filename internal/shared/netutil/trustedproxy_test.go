@@ -0,0 +1,69 @@
+package netutil
+
+import "testing"
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	trusted := NewTrustedProxies([]string{"10.0.0.0/8"})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		expect     string
+	}{
+		{
+			name:       "untrusted peer: headers ignored even if spoofed",
+			remoteAddr: "203.0.113.1:12345",
+			xff:        "1.2.3.4",
+			xRealIP:    "5.6.7.8",
+			expect:     "203.0.113.1",
+		},
+		{
+			name:       "trusted peer: first untrusted XFF hop wins",
+			remoteAddr: "10.0.0.1:443",
+			xff:        "198.51.100.9, 10.0.0.5, 10.0.0.1",
+			xRealIP:    "",
+			expect:     "198.51.100.9",
+		},
+		{
+			name:       "trusted peer: all XFF hops trusted falls back to X-Real-IP",
+			remoteAddr: "10.0.0.1:443",
+			xff:        "10.0.0.2, 10.0.0.3",
+			xRealIP:    "198.51.100.9",
+			expect:     "198.51.100.9",
+		},
+		{
+			name:       "trusted peer: no usable headers falls back to peer",
+			remoteAddr: "10.0.0.1:443",
+			xff:        "",
+			xRealIP:    "",
+			expect:     "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trusted.ClientIP(tt.remoteAddr, tt.xff, tt.xRealIP); got != tt.expect {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestIPAccessChecker_IsAllowedRequest(t *testing.T) {
+	trusted := NewTrustedProxies([]string{"10.0.0.0/8"})
+	checker := NewIPAccessChecker(nil, []string{"198.51.100.9"})
+
+	// Spoofed XFF from an untrusted peer must not bypass the deny rule
+	// targeting the peer's own address.
+	if checker.IsAllowedRequest("198.51.100.9:1234", "1.2.3.4", "", trusted) {
+		t.Fatal("expected deny rule on the untrusted peer address to still apply")
+	}
+
+	// A trusted proxy forwarding the same denied address must also be
+	// rejected once the real client IP is resolved.
+	if checker.IsAllowedRequest("10.0.0.1:443", "198.51.100.9", "", trusted) {
+		t.Fatal("expected deny rule to apply to the resolved client IP behind a trusted proxy")
+	}
+}